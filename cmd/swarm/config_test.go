@@ -87,6 +87,7 @@ func TestConfigCmdLineOverrides(t *testing.T) {
 		fmt.Sprintf("--%s", SwarmNetworkIdFlag.Name), "42",
 		fmt.Sprintf("--%s", SwarmPortFlag.Name), httpPort,
 		fmt.Sprintf("--%s", SwarmSyncDisabledFlag.Name),
+		fmt.Sprintf("--%s", SwarmSyncCompactHashesFlag.Name),
 		fmt.Sprintf("--%s", CorsStringFlag.Name), "*",
 		fmt.Sprintf("--%s", SwarmAccountFlag.Name), account.Address.String(),
 		fmt.Sprintf("--%s", SwarmDeliverySkipCheckFlag.Name),
@@ -130,6 +131,10 @@ func TestConfigCmdLineOverrides(t *testing.T) {
 		t.Fatal("Expected Sync to be disabled, but is true")
 	}
 
+	if !info.SyncCompactHashes {
+		t.Fatal("Expected SyncCompactHashes to be enabled, but it is not")
+	}
+
 	if !info.DeliverySkipCheck {
 		t.Fatal("Expected DeliverySkipCheck to be enabled, but it is not")
 	}
@@ -264,6 +269,7 @@ func TestConfigEnvVars(t *testing.T) {
 	envVars = append(envVars, fmt.Sprintf("%s=%s", SwarmNetworkIdFlag.EnvVar, "999"))
 	envVars = append(envVars, fmt.Sprintf("%s=%s", CorsStringFlag.EnvVar, "*"))
 	envVars = append(envVars, fmt.Sprintf("%s=%s", SwarmSyncDisabledFlag.EnvVar, "true"))
+	envVars = append(envVars, fmt.Sprintf("%s=%s", SwarmSyncCompactHashesFlag.EnvVar, "false"))
 	envVars = append(envVars, fmt.Sprintf("%s=%s", SwarmDeliverySkipCheckFlag.EnvVar, "true"))
 
 	dir, err := ioutil.TempDir("", "bzztest")
@@ -345,6 +351,10 @@ func TestConfigEnvVars(t *testing.T) {
 		t.Fatal("Expected Sync to be disabled, but is true")
 	}
 
+	if info.SyncCompactHashes {
+		t.Fatal("Expected SyncCompactHashes to be disabled, but is true")
+	}
+
 	if !info.DeliverySkipCheck {
 		t.Fatal("Expected DeliverySkipCheck to be enabled, but it is not")
 	}
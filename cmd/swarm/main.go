@@ -116,6 +116,11 @@ var (
 		Usage:  "Duration for sync subscriptions update after no new peers are added (default 15s)",
 		EnvVar: SWARM_ENV_SYNC_UPDATE_DELAY,
 	}
+	SwarmSyncCompactHashesFlag = cli.BoolFlag{
+		Name:   "sync-compact-hashes",
+		Usage:  "Request offered hashes in a compact encoding from peers that support it (default false)",
+		EnvVar: SWARM_ENV_SYNC_COMPACT_HASHES,
+	}
 	SwarmLightNodeEnabled = cli.BoolFlag{
 		Name:   "lightnode",
 		Usage:  "Enable Swarm LightNode (default false)",
@@ -542,6 +547,7 @@ pv(1) tool to get a progress bar:
 		SwarmSwapAPIFlag,
 		SwarmSyncDisabledFlag,
 		SwarmSyncUpdateDelay,
+		SwarmSyncCompactHashesFlag,
 		SwarmLightNodeEnabled,
 		SwarmDeliverySkipCheckFlag,
 		SwarmListenAddrFlag,
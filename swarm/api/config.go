@@ -66,6 +66,7 @@ type Config struct {
 	DeliverySkipCheck bool
 	LightNodeEnabled  bool
 	SyncUpdateDelay   time.Duration
+	SyncCompactHashes bool
 	SwapAPI           string
 	Cors              string
 	BzzAccount        string
@@ -93,6 +94,7 @@ func NewConfig() (c *Config) {
 		SyncingSkipCheck:  false,
 		DeliverySkipCheck: true,
 		SyncUpdateDelay:   15 * time.Second,
+		SyncCompactHashes: false,
 		SwapAPI:           "",
 	}
 
@@ -32,6 +32,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	p2ptest "github.com/ethereum/go-ethereum/p2p/testing"
 	"github.com/ethereum/go-ethereum/swarm/network"
@@ -85,6 +86,12 @@ func createGlobalStore() (string, *mockdb.GlobalStore, error) {
 }
 
 func newStreamerTester(t *testing.T) (*p2ptest.ProtocolTester, *Registry, *storage.LocalStore, func(), error) {
+	return newStreamerTesterWithOptions(t, nil, (*Registry).runProtocol)
+}
+
+// newStreamerTesterWithOptions constructs the streamer with options and runs
+// the tested peer with the protocol run function, such as (*Registry).runProtocol.
+func newStreamerTesterWithOptions(t *testing.T, options *RegistryOptions, run func(*Registry, *p2p.Peer, p2p.MsgReadWriter) error) (*p2ptest.ProtocolTester, *Registry, *storage.LocalStore, func(), error) {
 	// setup
 	addr := network.RandomAddr() // tested peers peer address
 	to := network.NewKademlia(addr.OAddr, network.NewKadParams())
@@ -114,12 +121,14 @@ func newStreamerTester(t *testing.T) (*p2ptest.ProtocolTester, *Registry, *stora
 
 	delivery := NewDelivery(to, netStore)
 	netStore.NewNetFetcherFunc = network.NewFetcherFactory(delivery.RequestFromPeers, true).New
-	streamer := NewRegistry(addr, delivery, netStore, state.NewInmemoryStore(), nil)
+	streamer := NewRegistry(addr, delivery, netStore, state.NewInmemoryStore(), options)
 	teardown := func() {
 		streamer.Close()
 		removeDataDir()
 	}
-	protocolTester := p2ptest.NewProtocolTester(t, network.NewNodeIDFromAddr(addr), 1, streamer.runProtocol)
+	protocolTester := p2ptest.NewProtocolTester(t, network.NewNodeIDFromAddr(addr), 1, func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+		return run(streamer, p, rw)
+	})
 
 	err = waitForPeers(streamer, 1*time.Second, 1)
 	if err != nil {
@@ -0,0 +1,172 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// Capability is an optional protocol feature that a downstream peer
+// advertises to the upstream peer in SubscribeMsg.
+type Capability uint
+
+const (
+	// CapCompactHashes signals that the downstream peer accepts
+	// OfferedHashesMsg hashes in the HashesEncodingPrefix encoding.
+	CapCompactHashes Capability = iota + 1
+)
+
+// HashesEncoding identifies the encoding of the Hashes field
+// of OfferedHashesMsg.
+type HashesEncoding uint
+
+const (
+	// HashesEncodingPrefix stores the bit prefix shared by all hashes of the
+	// batch only once, followed by the remaining bits of each hash.
+	HashesEncodingPrefix HashesEncoding = iota + 1
+)
+
+// hashBits is the number of bits in a hash.
+const hashBits = HashSize * 8
+
+var (
+	errHashesLength       = errors.New("hashes length is not a multiple of hash size")
+	errEmptyHashes        = errors.New("empty encoded hashes")
+	errInvalidHashesCount = errors.New("invalid encoded hashes count")
+	errInvalidHashes      = errors.New("invalid encoded hashes length")
+	errHashesSizeExceeded = errors.New("decoded hashes size exceeds limit")
+)
+
+// encodeHashes encodes a flat concatenation of hashes with the
+// HashesEncodingPrefix encoding: one byte holding the number of prefix bits
+// shared by all hashes, the number of hashes as uvarint and a bit stream of
+// the prefix followed by the remaining bits of every hash, padded with zero
+// bits to a whole byte. Hashes of a single proximity order bin share at least
+// po+1 bits, which are sent only once for the whole batch.
+func encodeHashes(hashes []byte) ([]byte, error) {
+	if len(hashes)%HashSize != 0 {
+		return nil, errHashesLength
+	}
+	n := len(hashes) / HashSize
+	// the prefix length has to fit into a single byte
+	p := 0
+	if n > 0 {
+		p = hashBits - 1
+	}
+	for i := HashSize; i < len(hashes) && p > 0; i += HashSize {
+		if l := commonPrefixBits(hashes[:HashSize], hashes[i:i+HashSize]); l < p {
+			p = l
+		}
+	}
+	suffixBits := hashBits - p
+
+	header := make([]byte, 1+binary.MaxVarintLen64)
+	header[0] = byte(p)
+	headerLen := 1 + binary.PutUvarint(header[1:], uint64(n))
+
+	encoded := make([]byte, headerLen+(p+n*suffixBits+7)/8)
+	copy(encoded, header[:headerLen])
+	data := encoded[headerLen:]
+	copyBits(data, 0, hashes, 0, p)
+	for i := 0; i < n; i++ {
+		copyBits(data, p+i*suffixBits, hashes[i*HashSize:], p, suffixBits)
+	}
+	return encoded, nil
+}
+
+// decodeHashes decodes hashes encoded with encodeHashes back into a flat
+// concatenation of hashes. Decoding fails if the result would be larger than
+// limit bytes.
+func decodeHashes(encoded []byte, limit int) ([]byte, error) {
+	p, count, headerLen, err := decodeHashesHeader(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(limit/HashSize) {
+		return nil, errHashesSizeExceeded
+	}
+	n := int(count)
+	suffixBits := hashBits - p
+	data := encoded[headerLen:]
+	if len(data) != (p+n*suffixBits+7)/8 {
+		return nil, errInvalidHashes
+	}
+
+	hashes := make([]byte, n*HashSize)
+	for i := 0; i < n; i++ {
+		hash := hashes[i*HashSize : (i+1)*HashSize]
+		copyBits(hash, 0, data, 0, p)
+		copyBits(hash, p, data, p+i*suffixBits, suffixBits)
+	}
+	return hashes, nil
+}
+
+// decodeHashesHeader returns the number of prefix bits, the number of hashes
+// and the header length of hashes encoded with encodeHashes.
+func decodeHashesHeader(encoded []byte) (prefixBits int, count uint64, headerLen int, err error) {
+	if len(encoded) == 0 {
+		return 0, 0, 0, errEmptyHashes
+	}
+	count, l := binary.Uvarint(encoded[1:])
+	if l <= 0 {
+		return 0, 0, 0, errInvalidHashesCount
+	}
+	return int(encoded[0]), count, 1 + l, nil
+}
+
+// commonPrefixBits returns the number of leading bits shared by a and b.
+func commonPrefixBits(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	if len(a) < len(b) {
+		return len(a) * 8
+	}
+	return len(b) * 8
+}
+
+// copyBits copies n bits from src starting at bit srcOff to dst starting
+// at bit dstOff. Bits of dst that are not set in src are left unchanged,
+// so dst is expected to be zeroed.
+func copyBits(dst []byte, dstOff int, src []byte, srcOff int, n int) {
+	for n > 0 {
+		k := 8
+		if n < k {
+			k = n
+		}
+		// read k bits into the most significant bits of v
+		i, shift := srcOff/8, uint(srcOff%8)
+		v := src[i] << shift
+		if int(shift)+k > 8 {
+			v |= src[i+1] >> (8 - shift)
+		}
+		v &= 0xff << uint(8-k)
+		// write them at the destination offset
+		i, shift = dstOff/8, uint(dstOff%8)
+		dst[i] |= v >> shift
+		if int(shift)+k > 8 {
+			dst[i+1] |= v << (8 - shift)
+		}
+		srcOff += k
+		dstOff += k
+		n -= k
+	}
+}
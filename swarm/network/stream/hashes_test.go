@@ -0,0 +1,204 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// binHashes returns a flat concatenation of count random hashes that
+// all fall into the proximity order bin po of base.
+func binHashes(base []byte, po int, count int) []byte {
+	hashes := make([]byte, count*HashSize)
+	rand.Read(hashes)
+	for i := 0; i < len(hashes); i += HashSize {
+		hash := hashes[i : i+HashSize]
+		for b := 0; b <= po; b++ {
+			bit := byte(0x80 >> uint(b%8))
+			v := base[b/8] & bit
+			if b == po {
+				// first bit differing from base
+				v ^= bit
+			}
+			hash[b/8] = hash[b/8]&^bit | v
+		}
+	}
+	return hashes
+}
+
+func TestHashesEncoding(t *testing.T) {
+	base := make([]byte, HashSize)
+	rand.Read(base)
+	same := bytes.Repeat(base, 3)
+
+	for _, tc := range []struct {
+		name       string
+		hashes     []byte
+		prefixBits int
+	}{
+		{"empty", []byte{}, 0},
+		{"single", base, hashBits - 1},
+		{"same", same, hashBits - 1},
+		{"bin 0", binHashes(base, 0, 128), 1},
+		{"bin 7", binHashes(base, 7, 128), 8},
+		{"bin 12", binHashes(base, 12, 128), 13},
+		{"bin 255", binHashes(base, 255, 2), hashBits - 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded, err := encodeHashes(tc.hashes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int(encoded[0]) < tc.prefixBits {
+				t.Fatalf("expected prefix of at least %v bits, got %v", tc.prefixBits, encoded[0])
+			}
+			n := len(tc.hashes) / HashSize
+			headerLen := 1 + binary.PutUvarint(make([]byte, binary.MaxVarintLen64), uint64(n))
+			maxSize := headerLen + (tc.prefixBits+n*(hashBits-tc.prefixBits)+7)/8
+			if len(encoded) > maxSize {
+				t.Fatalf("expected encoded size of at most %v, got %v", maxSize, len(encoded))
+			}
+			decoded, err := decodeHashes(encoded, len(tc.hashes))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, tc.hashes) {
+				t.Fatalf("expected decoded hashes %x, got %x", tc.hashes, decoded)
+			}
+		})
+	}
+}
+
+func TestHashesEncodingInvalidLength(t *testing.T) {
+	hashes := binHashes(make([]byte, HashSize), 12, 4)
+	if _, err := encodeHashes(hashes[:len(hashes)-1]); err != errHashesLength {
+		t.Fatalf("expected error %v, got %v", errHashesLength, err)
+	}
+}
+
+func TestHashesDecodingErrors(t *testing.T) {
+	encoded, err := encodeHashes(binHashes(make([]byte, HashSize), 12, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		encoded []byte
+		limit   int
+	}{
+		{"empty", nil, 4 * HashSize},
+		{"missing count", encoded[:1], 4 * HashSize},
+		{"truncated", encoded[:len(encoded)-1], 4 * HashSize},
+		{"trailing data", append(encoded[:len(encoded):len(encoded)], 0), 4 * HashSize},
+		{"limit exceeded", encoded, 3 * HashSize},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := decodeHashes(tc.encoded, tc.limit); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestOfferedHashesMsgDecodeHashes(t *testing.T) {
+	hashes := binHashes(make([]byte, HashSize), 3, 16)
+	encoded, err := encodeHashes(hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := OfferedHashesMsg{
+		Hashes:   encoded,
+		Encoding: []HashesEncoding{HashesEncodingPrefix},
+	}
+	decoded, err := msg.decodeHashes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, hashes) {
+		t.Fatalf("expected decoded hashes %x, got %x", hashes, decoded)
+	}
+
+	msg = OfferedHashesMsg{
+		Hashes: hashes,
+	}
+	decoded, err = msg.decodeHashes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, hashes) {
+		t.Fatalf("expected raw hashes %x, got %x", hashes, decoded)
+	}
+
+	msg = OfferedHashesMsg{
+		Hashes:   hashes,
+		Encoding: []HashesEncoding{HashesEncodingPrefix + 1},
+	}
+	if _, err := msg.decodeHashes(); err == nil {
+		t.Fatal("expected unknown encoding error")
+	}
+}
+
+func TestOfferedHashesMsgString(t *testing.T) {
+	stream := NewStream("foo", "", false)
+	hashes := binHashes(make([]byte, HashSize), 8, 128)
+	encoded, err := encodeHashes(hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		msg      OfferedHashesMsg
+		expected string
+	}{
+		{
+			OfferedHashesMsg{Stream: stream, From: 1, To: 2, Hashes: hashes},
+			"Stream 'foo||h' [1-2] (128)",
+		},
+		{
+			OfferedHashesMsg{Stream: stream, From: 1, To: 2, Hashes: encoded, Encoding: []HashesEncoding{HashesEncodingPrefix}},
+			"Stream 'foo||h' [1-2] (128, encoding [1])",
+		},
+	} {
+		if s := tc.msg.String(); s != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, s)
+		}
+	}
+}
+
+// BenchmarkHashesEncoding logs the size of the encoded batch of 128
+// hashes relative to the raw size for hashes from different bins.
+func BenchmarkHashesEncoding(b *testing.B) {
+	base := make([]byte, HashSize)
+	rand.Read(base)
+
+	for _, po := range []int{0, 1, 2, 4, 8, 12, 16} {
+		hashes := binHashes(base, po, 128)
+		b.Run(fmt.Sprintf("po=%v", po), func(b *testing.B) {
+			var encoded []byte
+			for i := 0; i < b.N; i++ {
+				encoded, _ = encodeHashes(hashes)
+			}
+			b.Logf("raw %v bytes, encoded %v bytes, ratio %.4f", len(hashes), len(encoded), float64(len(encoded))/float64(len(hashes)))
+		})
+	}
+}
@@ -64,6 +64,20 @@ type SubscribeMsg struct {
 	Stream   Stream
 	History  *Range `rlp:"nil"`
 	Priority uint8  // delivered on priority channel
+	// Capabilities are optional features supported by the subscriber.
+	// If empty, the message is encoded the same way as for peers
+	// that do not know about capabilities.
+	Capabilities []Capability `rlp:"tail"`
+}
+
+// hasCapability returns true if the subscriber advertised the capability c.
+func (m SubscribeMsg) hasCapability(c Capability) bool {
+	for _, mc := range m.Capabilities {
+		if mc == c {
+			return true
+		}
+	}
+	return false
 }
 
 // RequestSubscriptionMsg is the protocol msg for a node to request subscription to a
@@ -103,7 +117,8 @@ func (p *Peer) handleSubscribeMsg(ctx context.Context, req *SubscribeMsg) (err e
 	if err != nil {
 		return err
 	}
-	os, err := p.setServer(req.Stream, s, req.Priority)
+	compactHashes := req.hasCapability(CapCompactHashes)
+	os, err := p.setServer(req.Stream, s, req.Priority, compactHashes)
 	if err != nil {
 		return err
 	}
@@ -128,7 +143,7 @@ func (p *Peer) handleSubscribeMsg(ctx context.Context, req *SubscribeMsg) (err e
 			return err
 		}
 
-		os, err := p.setServer(getHistoryStream(req.Stream), s, getHistoryPriority(req.Priority))
+		os, err := p.setServer(getHistoryStream(req.Stream), s, getHistoryPriority(req.Priority), compactHashes)
 		if err != nil {
 			return err
 		}
@@ -173,13 +188,45 @@ type OfferedHashesMsg struct {
 	From, To       uint64 // peer and db-specific entry count
 	Hashes         []byte // stream of hashes (128)
 	*HandoverProof        // HandoverProof
+	// Encoding holds the encoding of Hashes if they are not sent as
+	// a flat concatenation. It is empty for raw hashes, which keeps the
+	// message compatible with peers that do not support encodings.
+	Encoding []HashesEncoding `rlp:"tail"`
 }
 
 // String pretty prints OfferedHashesMsg
 func (m OfferedHashesMsg) String() string {
+	if len(m.Encoding) > 0 {
+		_, count, _, err := decodeHashesHeader(m.Hashes)
+		if err != nil {
+			return fmt.Sprintf("Stream '%v' [%v-%v] (encoding %v: %v)", m.Stream, m.From, m.To, m.Encoding, err)
+		}
+		return fmt.Sprintf("Stream '%v' [%v-%v] (%v, encoding %v)", m.Stream, m.From, m.To, count, m.Encoding)
+	}
 	return fmt.Sprintf("Stream '%v' [%v-%v] (%v)", m.Stream, m.From, m.To, len(m.Hashes)/HashSize)
 }
 
+// decodeHashes returns the offered hashes as a flat concatenation,
+// decoding them if they were sent in a compact encoding.
+func (m OfferedHashesMsg) decodeHashes() ([]byte, error) {
+	if len(m.Encoding) == 0 {
+		return m.Hashes, nil
+	}
+	if len(m.Encoding) > 1 {
+		return nil, fmt.Errorf("invalid number of hashes encodings %v", len(m.Encoding))
+	}
+	switch m.Encoding[0] {
+	case HashesEncodingPrefix:
+		hashes, err := decodeHashes(m.Hashes, int(Spec.MaxMsgSize))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding offered hashes: %v", err)
+		}
+		return hashes, nil
+	default:
+		return nil, fmt.Errorf("unknown hashes encoding %v", m.Encoding[0])
+	}
+}
+
 // handleOfferedHashesMsg protocol msg handler calls the incoming streamer interface
 // Filter method
 func (p *Peer) handleOfferedHashesMsg(ctx context.Context, req *OfferedHashesMsg) error {
@@ -195,7 +242,10 @@ func (p *Peer) handleOfferedHashesMsg(ctx context.Context, req *OfferedHashesMsg
 	if err != nil {
 		return err
 	}
-	hashes := req.Hashes
+	hashes, err := req.decodeHashes()
+	if err != nil {
+		return err
+	}
 	want, err := bv.New(len(hashes) / HashSize)
 	if err != nil {
 		return fmt.Errorf("error initiaising bitvector of length %v: %v", len(hashes)/HashSize, err)
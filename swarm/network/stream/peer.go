@@ -59,7 +59,9 @@ type Peer struct {
 	// that are set on Registry.Subscribe and used
 	// on creating a new client in offered hashes handler.
 	clientParams map[Stream]*clientParams
-	quit         chan struct{}
+	// version is the negotiated streamer protocol version
+	version uint
+	quit    chan struct{}
 }
 
 type WrappedPriorityMsg struct {
@@ -76,6 +78,7 @@ func NewPeer(peer *protocols.Peer, streamer *Registry) *Peer {
 		servers:      make(map[Stream]*server),
 		clients:      make(map[Stream]*client),
 		clientParams: make(map[Stream]*clientParams),
+		version:      Spec.Version,
 		quit:         make(chan struct{}),
 	}
 	ctx, cancel := context.WithCancel(context.Background())
@@ -182,6 +185,17 @@ func (p *Peer) SendOfferedHashes(s *server, f, t uint64) error {
 		To:            to,
 		Stream:        s.stream,
 	}
+	if s.compactHashes {
+		// send the compact encoding only if it is actually smaller,
+		// which is not the case for hashes without a common prefix
+		encoded, err := encodeHashes(hashes)
+		if err != nil {
+			log.Warn("Swarm syncer offer batch: sending raw hashes", "peer", p.ID(), "stream", s.stream, "err", err)
+		} else if len(encoded) < len(hashes) {
+			msg.Hashes = encoded
+			msg.Encoding = []HashesEncoding{HashesEncodingPrefix}
+		}
+	}
 	log.Trace("Swarm syncer offer batch", "peer", p.ID(), "stream", s.stream, "len", len(hashes), "from", from, "to", to)
 	return p.SendPriority(ctx, msg, s.priority)
 }
@@ -197,7 +211,7 @@ func (p *Peer) getServer(s Stream) (*server, error) {
 	return server, nil
 }
 
-func (p *Peer) setServer(s Stream, o Server, priority uint8) (*server, error) {
+func (p *Peer) setServer(s Stream, o Server, priority uint8, compactHashes bool) (*server, error) {
 	p.serverMu.Lock()
 	defer p.serverMu.Unlock()
 
@@ -205,9 +219,10 @@ func (p *Peer) setServer(s Stream, o Server, priority uint8) (*server, error) {
 		return nil, fmt.Errorf("server %s already registered", s)
 	}
 	os := &server{
-		Server:   o,
-		stream:   s,
-		priority: priority,
+		Server:        o,
+		stream:        s,
+		priority:      priority,
+		compactHashes: compactHashes,
 	}
 	p.servers[s] = os
 	return os, nil
@@ -60,6 +60,7 @@ type Registry struct {
 	delivery       *Delivery
 	intervalsStore state.Store
 	doRetrieve     bool
+	compactHashes  bool
 }
 
 // RegistryOptions holds optional values for NewRegistry constructor.
@@ -68,6 +69,10 @@ type RegistryOptions struct {
 	DoSync          bool
 	DoRetrieve      bool
 	SyncUpdateDelay time.Duration
+	// CompactHashes advertises CapCompactHashes in subscriptions to peers
+	// that negotiated a protocol version with capabilities support, so
+	// that they may send offered hashes in a compact encoding.
+	CompactHashes bool
 }
 
 // NewRegistry is Streamer constructor
@@ -87,6 +92,7 @@ func NewRegistry(addr *network.BzzAddr, delivery *Delivery, syncChunkStore stora
 		delivery:       delivery,
 		intervalsStore: intervalsStore,
 		doRetrieve:     options.DoRetrieve,
+		compactHashes:  options.CompactHashes,
 	}
 	streamer.api = NewAPI(streamer)
 	delivery.getPeer = streamer.getPeer
@@ -283,6 +289,9 @@ func (r *Registry) Subscribe(peerId discover.NodeID, s Stream, h *Range, priorit
 		History:  h,
 		Priority: priority,
 	}
+	if r.compactHashes && peer.version >= capabilitiesVersion {
+		msg.Capabilities = []Capability{CapCompactHashes}
+	}
 	log.Debug("Subscribe ", "peer", peerId, "stream", s, "history", h)
 
 	return peer.SendPriority(context.TODO(), msg, priority)
@@ -365,7 +374,18 @@ func (r *Registry) peersCount() (c int) {
 
 // Run protocol run function
 func (r *Registry) Run(p *network.BzzPeer) error {
+	return r.run(p, Spec.Version)
+}
+
+// RunLegacy is the protocol run function for peers
+// that negotiated the LegacySpec protocol version
+func (r *Registry) RunLegacy(p *network.BzzPeer) error {
+	return r.run(p, LegacySpec.Version)
+}
+
+func (r *Registry) run(p *network.BzzPeer, version uint) error {
 	sp := NewPeer(p.Peer, r)
+	sp.version = version
 	r.setPeer(sp)
 	defer r.deletePeer(sp)
 	defer close(sp.quit)
@@ -445,12 +465,20 @@ func (r *Registry) updateSyncing() {
 }
 
 func (r *Registry) runProtocol(p *p2p.Peer, rw p2p.MsgReadWriter) error {
-	peer := protocols.NewPeer(p, rw, Spec)
+	return r.runProtocolSpec(p, rw, Spec, r.Run)
+}
+
+func (r *Registry) runLegacyProtocol(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	return r.runProtocolSpec(p, rw, LegacySpec, r.RunLegacy)
+}
+
+func (r *Registry) runProtocolSpec(p *p2p.Peer, rw p2p.MsgReadWriter, spec *protocols.Spec, run func(*network.BzzPeer) error) error {
+	peer := protocols.NewPeer(p, rw, spec)
 	bp := network.NewBzzPeer(peer, r.addr)
 	np := network.NewPeer(bp, r.delivery.kad)
 	r.delivery.kad.On(np)
 	defer r.delivery.kad.Off(np)
-	return r.Run(bp)
+	return run(bp)
 }
 
 // HandleMsg is the message handler that delegates incoming messages
@@ -494,9 +522,10 @@ func (p *Peer) HandleMsg(ctx context.Context, msg interface{}) error {
 
 type server struct {
 	Server
-	stream       Stream
-	priority     uint8
-	currentBatch []byte
+	stream        Stream
+	priority      uint8
+	currentBatch  []byte
+	compactHashes bool
 }
 
 // Server interface for outgoing peer Streamer
@@ -639,7 +668,7 @@ func (c *clientParams) clientCreated() {
 // Spec is the spec of the streamer protocol
 var Spec = &protocols.Spec{
 	Name:       "stream",
-	Version:    6,
+	Version:    7,
 	MaxMsgSize: 10 * 1024 * 1024,
 	Messages: []interface{}{
 		UnsubscribeMsg{},
@@ -655,6 +684,20 @@ var Spec = &protocols.Spec{
 	},
 }
 
+// LegacySpec is the spec of the previous streamer protocol version.
+// It is offered together with Spec so that peers which predate
+// SubscribeMsg capabilities can still stream with this node.
+var LegacySpec = &protocols.Spec{
+	Name:       Spec.Name,
+	Version:    6,
+	MaxMsgSize: Spec.MaxMsgSize,
+	Messages:   Spec.Messages,
+}
+
+// capabilitiesVersion is the first streamer protocol version
+// which allows Capabilities in SubscribeMsg
+const capabilitiesVersion = 7
+
 func (r *Registry) Protocols() []p2p.Protocol {
 	return []p2p.Protocol{
 		{
@@ -665,6 +708,18 @@ func (r *Registry) Protocols() []p2p.Protocol {
 			// NodeInfo: ,
 			// PeerInfo: ,
 		},
+		LegacyProtocol(r.runLegacyProtocol),
+	}
+}
+
+// LegacyProtocol returns the p2p protocol of the LegacySpec streamer
+// protocol version with the run function.
+func LegacyProtocol(run func(*p2p.Peer, p2p.MsgReadWriter) error) p2p.Protocol {
+	return p2p.Protocol{
+		Name:    LegacySpec.Name,
+		Version: LegacySpec.Version,
+		Length:  LegacySpec.Length(),
+		Run:     run,
 	}
 }
 
@@ -24,6 +24,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/crypto/sha3"
 	p2ptest "github.com/ethereum/go-ethereum/p2p/testing"
+	bv "github.com/ethereum/go-ethereum/swarm/network/bitvector"
 )
 
 func TestStreamerSubscribe(t *testing.T) {
@@ -558,6 +559,269 @@ func TestStreamerDownstreamOfferedHashesMsgExchange(t *testing.T) {
 
 }
 
+// compactTestServer offers a batch of hashes that share a common prefix.
+type compactTestServer struct {
+	*testServer
+	hashes []byte
+}
+
+func (self *compactTestServer) SetNextBatch(from uint64, to uint64) ([]byte, uint64, uint64, *HandoverProof, error) {
+	return self.hashes, from + 1, to + 1, nil, nil
+}
+
+func TestStreamerUpstreamCompactHashesMsgExchange(t *testing.T) {
+	tester, streamer, _, teardown, err := newStreamerTester(t)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := NewStream("foo", "", false)
+	batch := binHashes(make([]byte, HashSize), 16, 128)
+	encoded, err := encodeHashes(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamer.RegisterServerFunc("foo", func(p *Peer, t string, live bool) (Server, error) {
+		return &compactTestServer{newTestServer(t), batch}, nil
+	})
+
+	peerID := tester.IDs[0]
+
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "Subscribe message",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 4,
+				Msg: &SubscribeMsg{
+					Stream:       stream,
+					History:      NewRange(5, 8),
+					Priority:     Top,
+					Capabilities: []Capability{CapCompactHashes},
+				},
+				Peer: peerID,
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 1,
+				Msg: &OfferedHashesMsg{
+					Stream: stream,
+					HandoverProof: &HandoverProof{
+						Handover: &Handover{},
+					},
+					Hashes:   encoded,
+					From:     6,
+					To:       9,
+					Encoding: []HashesEncoding{HashesEncodingPrefix},
+				},
+				Peer: peerID,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestStreamerUpstreamCompactHashesFallbackMsgExchange checks that hashes
+// without a common prefix are offered raw, even if the subscriber supports
+// the compact encoding, as encoding would not make the batch smaller.
+func TestStreamerUpstreamCompactHashesFallbackMsgExchange(t *testing.T) {
+	tester, streamer, _, teardown, err := newStreamerTester(t)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := NewStream("foo", "", false)
+	batch := append(make([]byte, HashSize), bytes.Repeat([]byte{0xff}, HashSize)...)
+
+	streamer.RegisterServerFunc("foo", func(p *Peer, t string, live bool) (Server, error) {
+		return &compactTestServer{newTestServer(t), batch}, nil
+	})
+
+	peerID := tester.IDs[0]
+
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "Subscribe message",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 4,
+				Msg: &SubscribeMsg{
+					Stream:       stream,
+					History:      NewRange(5, 8),
+					Priority:     Top,
+					Capabilities: []Capability{CapCompactHashes},
+				},
+				Peer: peerID,
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 1,
+				Msg: &OfferedHashesMsg{
+					Stream: stream,
+					HandoverProof: &HandoverProof{
+						Handover: &Handover{},
+					},
+					Hashes: batch,
+					From:   6,
+					To:     9,
+				},
+				Peer: peerID,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamerDownstreamCompactHashesMsgExchange(t *testing.T) {
+	tester, streamer, _, teardown, err := newStreamerTesterWithOptions(t, &RegistryOptions{
+		CompactHashes: true,
+	}, (*Registry).runProtocol)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := NewStream("foo", "", true)
+
+	var tc *testClient
+
+	streamer.RegisterClientFunc("foo", func(p *Peer, t string, live bool) (Client, error) {
+		tc = newTestClient(t)
+		return tc, nil
+	})
+
+	peerID := tester.IDs[0]
+	batch := binHashes(make([]byte, HashSize), 8, 16)
+	encoded, err := encodeHashes(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the test client does not want any of the offered hashes
+	want, err := bv.New(len(batch) / HashSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = streamer.Subscribe(peerID, stream, NewRange(5, 8), Top)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "Subscribe message",
+		Expects: []p2ptest.Expect{
+			{
+				Code: 4,
+				Msg: &SubscribeMsg{
+					Stream:       stream,
+					History:      NewRange(5, 8),
+					Priority:     Top,
+					Capabilities: []Capability{CapCompactHashes},
+				},
+				Peer: peerID,
+			},
+		},
+	},
+		p2ptest.Exchange{
+			Label: "WantedHashes message",
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg: &OfferedHashesMsg{
+						HandoverProof: &HandoverProof{
+							Handover: &Handover{},
+						},
+						Hashes:   encoded,
+						From:     5,
+						To:       8,
+						Stream:   stream,
+						Encoding: []HashesEncoding{HashesEncodingPrefix},
+					},
+					Peer: peerID,
+				},
+			},
+			Expects: []p2ptest.Expect{
+				{
+					Code: 2,
+					Msg: &WantedHashesMsg{
+						Stream: stream,
+						Want:   want.Bytes(),
+						From:   9,
+						To:     0,
+					},
+					Peer: peerID,
+				},
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tc.receivedHashes) != 16 {
+		t.Fatalf("Expected number of received hashes %v, got %v", 16, len(tc.receivedHashes))
+	}
+	for i := 0; i < len(batch); i += HashSize {
+		if _, ok := tc.receivedHashes[string(batch[i:i+HashSize])]; !ok {
+			t.Fatalf("Expected hash %x to be received", batch[i:i+HashSize])
+		}
+	}
+}
+
+// TestStreamerDownstreamCompactHashesLegacyPeer checks that capabilities are
+// not sent to peers running the legacy protocol version, as they are not able
+// to decode them.
+func TestStreamerDownstreamCompactHashesLegacyPeer(t *testing.T) {
+	tester, streamer, _, teardown, err := newStreamerTesterWithOptions(t, &RegistryOptions{
+		CompactHashes: true,
+	}, (*Registry).runLegacyProtocol)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamer.RegisterClientFunc("foo", func(p *Peer, t string, live bool) (Client, error) {
+		return newTestClient(t), nil
+	})
+
+	peerID := tester.IDs[0]
+	if v := streamer.getPeer(peerID).version; v != LegacySpec.Version {
+		t.Fatalf("Expected peer protocol version %v, got %v", LegacySpec.Version, v)
+	}
+
+	stream := NewStream("foo", "", true)
+	err = streamer.Subscribe(peerID, stream, NewRange(5, 8), Top)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "Subscribe message",
+		Expects: []p2ptest.Expect{
+			{
+				Code: 4,
+				Msg: &SubscribeMsg{
+					Stream:   stream,
+					History:  NewRange(5, 8),
+					Priority: Top,
+				},
+				Peer: peerID,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestStreamerRequestSubscriptionQuitMsgExchange(t *testing.T) {
 	tester, streamer, _, teardown, err := newStreamerTester(t)
 	defer teardown()
@@ -186,6 +186,7 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 		DoSync:          config.SyncEnabled,
 		DoRetrieve:      true,
 		SyncUpdateDelay: config.SyncUpdateDelay,
+		CompactHashes:   config.SyncCompactHashes,
 	})
 
 	// Swarm Hash Merklised Chunking for Arbitrary-length Document/File storage
@@ -435,6 +436,8 @@ func (self *Swarm) Stop() error {
 // implements the node.Service interface
 func (self *Swarm) Protocols() (protos []p2p.Protocol) {
 	protos = append(protos, self.bzz.Protocols()...)
+	// offer the previous streamer protocol version to peers that predate the current one
+	protos = append(protos, stream.LegacyProtocol(self.bzz.RunProtocol(stream.LegacySpec, self.streamer.RunLegacy)))
 
 	if self.ps != nil {
 		protos = append(protos, self.ps.Protocols()...)